@@ -0,0 +1,61 @@
+package redistest
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// active tracks every process-backed Redis that's currently running, so it
+// can be reaped if the test binary is interrupted before it calls Stop().
+var (
+	activeMu sync.Mutex
+	active   = map[int]*Redis{}
+)
+
+func registerActive(r *Redis) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	active[r.cmd.Process.Pid] = r
+}
+
+func unregisterActive(r *Redis) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	delete(active, r.cmd.Process.Pid)
+}
+
+func init() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		s := <-sig
+		reapActive()
+
+		// We've cleaned up, now let the signal do what it would've done
+		// without us in the way.
+		signal.Stop(sig)
+		p, err := os.FindProcess(os.Getpid())
+		if err == nil {
+			p.Signal(s)
+		}
+	}()
+}
+
+// reapActive force-kills every redis-server process group we're still
+// tracking. There's no equivalent hook for SIGKILL: that case is covered
+// on Linux by PR_SET_PDEATHSIG in procAttr instead.
+func reapActive() {
+	activeMu.Lock()
+	procs := make([]*Redis, 0, len(active))
+	for _, r := range active {
+		procs = append(procs, r)
+	}
+	activeMu.Unlock()
+
+	for _, r := range procs {
+		killGroup(r.cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,254 @@
+package redistest
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+)
+
+// clusterReadyTimeout bounds how long StartCluster waits for the cluster to
+// finish handshaking and agree that all slots are covered.
+const clusterReadyTimeout = 10 * time.Second
+
+// totalSlots is the fixed number of hash slots a Redis Cluster has.
+const totalSlots = 16384
+
+// clusterConfig collects the options gathered from a StartCluster call's
+// ClusterOptions.
+type clusterConfig struct {
+	startOpts StartOptions
+}
+
+// ClusterOption configures StartCluster.
+type ClusterOption func(*clusterConfig)
+
+// ClusterStartOptions applies StartOptions (e.g. a custom Config) to every
+// node in the cluster. Network is always forced to "tcp", since cluster
+// nodes need to dial each other.
+func ClusterStartOptions(opt StartOptions) ClusterOption {
+	return func(c *clusterConfig) {
+		c.startOpts = opt
+	}
+}
+
+// Cluster is a set of redis-server processes wired together as a Redis
+// Cluster.
+type Cluster struct {
+	// Nodes holds a handle for every node in the cluster.
+	Nodes []*Redis
+
+	// Addrs is the combined seed list, suitable for handing to a
+	// redis.NewClusterClient-style dialer.
+	Addrs []string
+}
+
+// StartCluster spawns n redis-server processes, introduces them to each
+// other with CLUSTER MEET and splits the 16384 hash slots evenly across
+// them. Requires at least 3 nodes, which is the smallest cluster Redis
+// will agree to run.
+func StartCluster(n int, opts ...ClusterOption) (*Cluster, error) {
+	if n < 3 {
+		return nil, fmt.Errorf("redistest: a cluster needs at least 3 nodes, got %d", n)
+	}
+
+	cfg := &clusterConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	nodes := make([]*Redis, 0, n)
+	for i := 0; i < n; i++ {
+		opt := cfg.startOpts
+		opt.Network = "tcp"
+		opt.Config = mergeConfig(opt.Config, map[string]string{
+			"cluster-enabled":      "yes",
+			"cluster-node-timeout": "5000",
+			"cluster-config-file":  fmt.Sprintf("nodes-%d.conf", i),
+		})
+
+		node, err := startProcess(opt)
+		if err != nil {
+			stopAll(nodes)
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	if err := meetNodes(nodes); err != nil {
+		stopAll(nodes)
+		return nil, err
+	}
+
+	if err := assignSlots(nodes); err != nil {
+		stopAll(nodes)
+		return nil, err
+	}
+
+	if err := waitClusterReady(nodes); err != nil {
+		stopAll(nodes)
+		return nil, err
+	}
+
+	addrs := make([]string, len(nodes))
+	for i, node := range nodes {
+		addrs[i] = node.Address
+	}
+
+	return &Cluster{
+		Nodes: nodes,
+		Addrs: addrs,
+	}, nil
+}
+
+// Stop tears down every node in the cluster and cleans up their temp dirs.
+func (c *Cluster) Stop() error {
+	return stopAll(c.Nodes)
+}
+
+func stopAll(nodes []*Redis) error {
+	var firstErr error
+	for _, node := range nodes {
+		if err := node.Stop(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// meetNodes introduces every node to the first one via CLUSTER MEET, which
+// is enough for the gossip protocol to spread the word to the rest.
+func meetNodes(nodes []*Redis) error {
+	seed := nodes[0]
+	for _, node := range nodes[1:] {
+		host, port, err := net.SplitHostPort(node.Address)
+		if err != nil {
+			return err
+		}
+
+		conn := seed.Pool.Get()
+		_, err = conn.Do("CLUSTER", "MEET", host, port)
+		conn.Close()
+		if err != nil {
+			return fmt.Errorf("CLUSTER MEET %s failed: %w", node.Address, err)
+		}
+	}
+	return nil
+}
+
+// assignSlots splits the keyspace evenly across the nodes.
+func assignSlots(nodes []*Redis) error {
+	per := totalSlots / len(nodes)
+	start := 0
+	for i, node := range nodes {
+		end := start + per - 1
+		if i == len(nodes)-1 {
+			end = totalSlots - 1
+		}
+
+		conn := node.Pool.Get()
+		_, err := conn.Do("CLUSTER", "ADDSLOTSRANGE", start, end)
+		conn.Close()
+		if err != nil {
+			return fmt.Errorf("CLUSTER ADDSLOTSRANGE %d %d on %s failed: %w", start, end, node.Address, err)
+		}
+
+		start = end + 1
+	}
+	return nil
+}
+
+// waitClusterReady polls every node until they all report cluster_state:ok,
+// meaning the gossip protocol has converged and every slot is covered.
+func waitClusterReady(nodes []*Redis) error {
+	deadline := time.Now().Add(clusterReadyTimeout)
+	for {
+		allOK := true
+		for _, node := range nodes {
+			conn := node.Pool.Get()
+			info, err := redis.String(conn.Do("CLUSTER", "INFO"))
+			conn.Close()
+			if err != nil || !strings.Contains(info, "cluster_state:ok") {
+				allOK = false
+				break
+			}
+		}
+
+		if allOK {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("redistest: cluster did not converge within %s", clusterReadyTimeout)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// StartReplica boots a redis-server that replicates from master. The
+// master must have been started with Network: "tcp", since a unix socket
+// can't be handed to the replicaof directive.
+func StartReplica(master *Redis) (*Redis, error) {
+	host, port, err := net.SplitHostPort(master.Address)
+	if err != nil {
+		return nil, fmt.Errorf("redistest: replica requires a master started with Network: \"tcp\": %w", err)
+	}
+
+	opt := StartOptions{
+		Network: "tcp",
+		Config: map[string]string{
+			"replicaof": fmt.Sprintf("%s %s", host, port),
+		},
+	}
+
+	replica, err := startProcess(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := waitReplicaSynced(replica); err != nil {
+		replica.Stop()
+		return nil, err
+	}
+
+	return replica, nil
+}
+
+// waitReplicaSynced polls a replica until it reports a live link to its
+// master.
+func waitReplicaSynced(r *Redis) error {
+	deadline := time.Now().Add(readyTimeout)
+	for {
+		conn := r.Pool.Get()
+		info, err := redis.String(conn.Do("INFO", "replication"))
+		conn.Close()
+		if err == nil && strings.Contains(info, "master_link_status:up") {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if err == nil {
+				err = fmt.Errorf("replica never reported master_link_status:up")
+			}
+			return fmt.Errorf("redistest: replica did not sync with master: %w", err)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// mergeConfig returns a new map containing base overridden by extra,
+// without mutating either input.
+func mergeConfig(base, extra map[string]string) map[string]string {
+	merged := make(map[string]string, len(base)+len(extra))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
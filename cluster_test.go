@@ -0,0 +1,60 @@
+package redistest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rubenv/redistest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartCluster(t *testing.T) {
+	requireRedisServer(t)
+
+	assert := assert.New(t)
+
+	cluster, err := redistest.StartCluster(3)
+	assert.NoError(err)
+	assert.NotNil(cluster)
+	defer cluster.Stop()
+
+	assert.Len(cluster.Nodes, 3)
+	assert.Len(cluster.Addrs, 3)
+
+	conn := cluster.Nodes[0].Pool.Get()
+	defer conn.Close()
+
+	info, err := redis.String(conn.Do("CLUSTER", "INFO"))
+	assert.NoError(err)
+	assert.Contains(info, "cluster_state:ok")
+}
+
+func TestStartReplica(t *testing.T) {
+	requireRedisServer(t)
+
+	assert := assert.New(t)
+
+	master, err := redistest.Start(redistest.StartOptions{Network: "tcp"})
+	assert.NoError(err)
+	assert.NotNil(master)
+	defer master.Stop()
+
+	replica, err := redistest.StartReplica(master)
+	assert.NoError(err)
+	assert.NotNil(replica)
+	defer replica.Stop()
+
+	conn := master.Pool.Get()
+	_, err = conn.Do("SET", "foo", "bar")
+	conn.Close()
+	assert.NoError(err)
+
+	assert.Eventually(func() bool {
+		conn := replica.Pool.Get()
+		defer conn.Close()
+
+		s, err := redis.String(conn.Do("GET", "foo"))
+		return err == nil && s == "bar"
+	}, 5*time.Second, 50*time.Millisecond)
+}
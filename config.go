@@ -0,0 +1,92 @@
+package redistest
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultConfig holds the directives redistest has always shipped with. Set
+// via StartOptions.Config to override any of them.
+var defaultConfig = map[string]string{
+	"appendonly": "no",
+	"save":       `""`,
+}
+
+// setupTransport prepares the listening address for redis-server and
+// returns the network/address pair to hand out on Redis, along with the
+// config directives needed to make redis-server listen there.
+func setupTransport(dir, network string) (string, string, map[string]string, error) {
+	if network == "" {
+		network = "unix"
+	}
+
+	switch network {
+	case "unix":
+		sockDir := path.Join(dir, "sock")
+		err := os.MkdirAll(sockDir, 0711)
+		if err != nil {
+			return "", "", nil, err
+		}
+
+		address := fmt.Sprintf("%s/redis.sock", sockDir)
+		return network, address, map[string]string{
+			"port":       "0",
+			"unixsocket": address,
+		}, nil
+	case "tcp":
+		// Ask the kernel for a free port, then hand it straight to
+		// redis-server. There's a small window where something else
+		// could grab it first, but it's the same approach tempredis
+		// uses and is good enough for tests.
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return "", "", nil, err
+		}
+		port := l.Addr().(*net.TCPAddr).Port
+		l.Close()
+
+		address := fmt.Sprintf("127.0.0.1:%d", port)
+		return network, address, map[string]string{
+			"bind": "127.0.0.1",
+			"port": strconv.Itoa(port),
+		}, nil
+	default:
+		return "", "", nil, fmt.Errorf("redistest: unknown network %q", network)
+	}
+}
+
+// renderConfig merges the library defaults, any user overrides and the
+// transport config into a redis.cnf file body. Transport directives
+// (port/unixsocket/bind) are applied last and always win: Network/Address
+// have already been computed from them and handed back to the caller, so
+// letting a Config entry quietly move where redis-server listens would
+// desync the two.
+func renderConfig(transport, overrides map[string]string) string {
+	merged := map[string]string{}
+	for k, v := range defaultConfig {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	for k, v := range transport {
+		merged[k] = v
+	}
+
+	keys := make([]string, 0, len(merged))
+	for k := range merged {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%s %s\n", k, merged[k])
+	}
+	return sb.String()
+}
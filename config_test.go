@@ -0,0 +1,36 @@
+package redistest_test
+
+import (
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rubenv/redistest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartWithConfigAndTCP(t *testing.T) {
+	requireRedisServer(t)
+
+	assert := assert.New(t)
+
+	red, err := redistest.Start(redistest.StartOptions{
+		Network: "tcp",
+		Config: map[string]string{
+			"maxmemory": "16mb",
+		},
+	})
+	assert.NoError(err)
+	assert.NotNil(red)
+	defer red.Stop()
+
+	assert.Equal("tcp", red.Network)
+
+	conn := red.Pool.Get()
+	defer conn.Close()
+
+	vals, err := redis.Strings(conn.Do("CONFIG", "GET", "maxmemory"))
+	assert.NoError(err)
+	assert.Equal([]string{"maxmemory", "16777216"}, vals)
+
+	assert.Contains(red.Logs(), "Ready to accept connections")
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package redistest
+
+import "syscall"
+
+// Freeze hangs the server, good for testing blocked connections.
+//
+// Not supported when running in ModeMini.
+func (s *Redis) Freeze() {
+	if s.cmd != nil {
+		s.cmd.Process.Signal(syscall.SIGSTOP)
+	}
+}
+
+// Continue resumes a server previously paused with Freeze.
+//
+// Not supported when running in ModeMini.
+func (s *Redis) Continue() {
+	if s.cmd != nil {
+		s.cmd.Process.Signal(syscall.SIGCONT)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package redistest
+
+// Freeze is unsupported on Windows: there's no SIGSTOP equivalent to pause
+// a process in place, so this is a no-op.
+func (s *Redis) Freeze() {
+}
+
+// Continue is unsupported on Windows; see Freeze.
+func (s *Redis) Continue() {
+}
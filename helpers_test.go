@@ -0,0 +1,17 @@
+package redistest_test
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// requireRedisServer skips the test when no redis-server binary is
+// installed, since these tests start real processes rather than falling
+// back to miniredis.
+func requireRedisServer(t *testing.T) {
+	t.Helper()
+
+	if _, err := exec.LookPath("redis-server"); err != nil {
+		t.Skip("redis-server not installed, skipping")
+	}
+}
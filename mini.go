@@ -0,0 +1,34 @@
+package redistest
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gomodule/redigo/redis"
+)
+
+// startMini boots an in-process, pure-Go Redis implementation backed by
+// miniredis. Used as the ModeAuto fallback when no redis-server binary is
+// available or it doesn't become ready in time, and directly when ModeMini
+// is requested.
+func startMini() (*Redis, error) {
+	m, err := miniredis.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	network := "tcp"
+	address := m.Addr()
+
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial(network, address)
+		},
+	}
+
+	return &Redis{
+		mini: m,
+
+		Pool:    pool,
+		Network: network,
+		Address: address,
+	}, nil
+}
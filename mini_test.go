@@ -0,0 +1,30 @@
+package redistest_test
+
+import (
+	"testing"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/rubenv/redistest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartModeMini(t *testing.T) {
+	assert := assert.New(t)
+
+	red, err := redistest.Start(redistest.StartOptions{Mode: redistest.ModeMini})
+	assert.NoError(err)
+	assert.NotNil(red)
+	defer red.Stop()
+
+	conn := red.Pool.Get()
+	defer conn.Close()
+
+	_, err = conn.Do("SET", "foo", "bar")
+	assert.NoError(err)
+
+	s, err := redis.String(conn.Do("GET", "foo"))
+	assert.NoError(err)
+	assert.Equal(s, "bar")
+
+	assert.Equal("", red.Logs())
+}
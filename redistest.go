@@ -1,25 +1,55 @@
 // Spawns a Redis server. Ideal for unit tests where you want a clean instance
 // each time. Then clean up afterwards.
 //
-// Requires Redis to be installed on your system (but it doesn't have to be running).
+// Uses a real Redis if it's installed on your system (it doesn't have to be
+// running), falling back to an in-process pure-Go implementation otherwise.
 package redistest
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/gomodule/redigo/redis"
 )
 
+// errNoBinary is returned by startProcess when no redis-server executable
+// could be found. Together with errNotReady, it's the only failure ModeAuto
+// falls back to miniredis for; anything else (bad Config, a tempdir/transport
+// failure, ...) is a real error and is returned to the caller instead of
+// being masked.
+var errNoBinary = errors.New("redistest: no redis-server binary found")
+
+// errNotReady is returned by startProcess when redis-server never printed
+// readyMarker within readyTimeout. ModeAuto treats this the same as
+// errNoBinary: a system where the installed redis-server can't come up in
+// time is, for our purposes, a system without a usable one.
+var errNotReady = errors.New("redistest: redis-server did not become ready in time")
+
+// readyMarker is the line redis-server logs once it's accepting
+// connections.
+const readyMarker = "Ready to accept connections"
+
+// readyTimeout bounds how long we wait for readyMarker before giving up.
+const readyTimeout = 10 * time.Second
+
 type Redis struct {
-	dir string
-	cmd *exec.Cmd
+	dir       string
+	cmd       *exec.Cmd
+	mini      *miniredis.Miniredis
+	logs      *logCapture
+	stopGrace time.Duration
 
 	// A redis pool pre-configured to talk to the redis server
 	Pool *redis.Pool
@@ -34,6 +64,71 @@ type Redis struct {
 	stdout io.ReadCloser
 }
 
+// Logs returns everything redis-server has printed to stdout so far.
+// Useful for debugging flaky tests. Returns an empty string in ModeMini,
+// since there's no process log to capture.
+func (s *Redis) Logs() string {
+	if s == nil || s.logs == nil {
+		return ""
+	}
+	return s.logs.String()
+}
+
+// Mode controls how Start() obtains a Redis server.
+type Mode int
+
+const (
+	// ModeAuto uses a real redis-server binary when one can be found on the
+	// system, and transparently falls back to an in-process miniredis
+	// instance when none is found or the one found doesn't become ready
+	// within readyTimeout. This is the default.
+	ModeAuto Mode = iota
+
+	// ModeProcess requires a real redis-server binary and fails if one
+	// isn't available.
+	ModeProcess
+
+	// ModeMini always uses the in-process miniredis implementation, never
+	// spawning a redis-server process. Handy for CI images that don't
+	// ship Redis.
+	ModeMini
+)
+
+// StartOptions configures Start(). The zero value runs in ModeAuto over a
+// unix socket.
+type StartOptions struct {
+	// Mode selects how the server is started. Defaults to ModeAuto.
+	Mode Mode
+
+	// Network selects the transport redis-server listens on: "unix"
+	// (default) or "tcp". TCP binds to 127.0.0.1 on an ephemeral port,
+	// which is required on Windows and in containers where the temp-dir
+	// socket path can't be shared with the process under test. Ignored
+	// in ModeMini, which always listens on TCP.
+	Network string
+
+	// Config holds extra directives to render into redis.cnf, keyed by
+	// directive name (e.g. "maxmemory", "requirepass", "databases").
+	// Values are written verbatim after the key, so string values that
+	// need quoting (like `save ""`) must include the quotes themselves.
+	// Entries here override the library's defaults.
+	//
+	// Ignored in ModeMini: miniredis has no config file, so none of these
+	// directives apply there. That includes the ModeAuto fallback, so a
+	// caller relying on ModeAuto for portability and setting e.g.
+	// "requirepass" or "maxmemory" will silently get an unauthenticated,
+	// unbounded instance on a system without redis-server installed.
+	Config map[string]string
+
+	// StopGrace bounds how long Stop() waits for redis-server to exit on
+	// its own after being interrupted, before escalating to SIGKILL.
+	// Defaults to defaultStopGrace.
+	StopGrace time.Duration
+}
+
+// defaultStopGrace is used whenever StartOptions.StopGrace is left zero.
+const defaultStopGrace = 5 * time.Second
+
 // Start a new Redis database, on temporary storage.
 //
 // This database has persistance disabled for performance, so it might run faster
@@ -41,32 +136,46 @@ type Redis struct {
 // crashes, but we don't care about that anyway during unit testing.
 //
 // Use the Pool field to access the database connection
-func Start() (*Redis, error) {
+func Start(opts ...StartOptions) (*Redis, error) {
+	opt := StartOptions{}
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	switch opt.Mode {
+	case ModeMini:
+		return startMini()
+	case ModeProcess:
+		return startProcess(opt)
+	default:
+		r, err := startProcess(opt)
+		if err != nil {
+			if errors.Is(err, errNoBinary) || errors.Is(err, errNotReady) {
+				// No usable redis-server on this system, fall back to a
+				// pure-Go implementation.
+				return startMini()
+			}
+			return nil, err
+		}
+		return r, nil
+	}
+}
+
+// startProcess spawns a real redis-server binary.
+func startProcess(opt StartOptions) (*Redis, error) {
 	// Prepare data directory
 	dir, err := ioutil.TempDir("", "redistest")
 	if err != nil {
 		return nil, err
 	}
 
-	sockDir := path.Join(dir, "sock")
-	err = os.MkdirAll(sockDir, 0711)
+	network, address, conf, err := setupTransport(dir, opt.Network)
 	if err != nil {
 		return nil, err
 	}
 
-	// Config file
-	//
-	// We're always using unix sockets, but if someone wants to make this
-	// conditional and use TCP sockets on Windows: feel free to send a PR.
-	network := "unix"
-	address := fmt.Sprintf("%s/redis.sock", sockDir)
 	configFile := path.Join(dir, "redis.cnf")
-	err = ioutil.WriteFile(configFile, []byte(fmt.Sprintf(`
-port 0
-unixsocket %s
-appendonly no
-save ""
-`, address)), 0644)
+	err = ioutil.WriteFile(configFile, []byte(renderConfig(conf, opt.Config)), 0644)
 	if err != nil {
 		return nil, err
 	}
@@ -81,6 +190,8 @@ save ""
 	cmd := exec.Command(path.Join(binPath, "redis-server"),
 		configFile,
 	)
+	cmd.Dir = dir
+	cmd.SysProcAttr = procAttr()
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		return nil, err
@@ -97,6 +208,26 @@ save ""
 		return nil, abort("Failed to start Redis", cmd, stderr, stdout, err)
 	}
 
+	// redis-server writes nothing of interest to stderr in normal
+	// operation, but drain it anyway so it can never block the process.
+	go io.Copy(ioutil.Discard, stderr)
+
+	logs := &logCapture{}
+	ready := make(chan struct{})
+	exited := make(chan struct{})
+	go watchLog(stdout, logs, ready, exited)
+
+	select {
+	case <-ready:
+	case <-exited:
+		cmd.Wait()
+		return nil, fmt.Errorf("redis-server exited before becoming ready:\n%s", logs.String())
+	case <-time.After(readyTimeout):
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("%w:\n%s", errNotReady, logs.String())
+	}
+
 	// Connect to Redis
 	pool := &redis.Pool{
 		Dial: func() (redis.Conn, error) {
@@ -104,20 +235,22 @@ save ""
 		},
 	}
 
-	err = retry(func() error {
-		conn := pool.Get()
-		defer conn.Close()
-
-		_, err := conn.Do("PING")
-		return err
-	}, 1000, 10*time.Millisecond)
+	// The ready marker means redis-server is listening, so this is just a
+	// final sanity check, not a retry loop.
+	conn := pool.Get()
+	_, err = conn.Do("PING")
+	conn.Close()
 	if err != nil {
-		return nil, abort("Failed to connect to DB", cmd, stderr, stdout, err)
+		cmd.Process.Signal(os.Interrupt)
+		cmd.Wait()
+		return nil, fmt.Errorf("Redis reported ready but PING failed: %s\n%s", err, logs.String())
 	}
 
 	pg := &Redis{
-		cmd: cmd,
-		dir: dir,
+		cmd:       cmd,
+		dir:       dir,
+		logs:      logs,
+		stopGrace: opt.StopGrace,
 
 		Pool:    pool,
 		Network: network,
@@ -127,26 +260,99 @@ save ""
 		stdout: stdout,
 	}
 
+	registerActive(pg)
+
 	return pg, nil
 }
 
+// logCapture buffers everything a redis-server process writes to stdout,
+// safe for concurrent reads from Redis.Logs() while the watcher goroutine
+// keeps writing.
+type logCapture struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *logCapture) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.Write(p)
+}
+
+func (l *logCapture) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
+
+// watchLog scans a redis-server's stdout, mirroring every line into logs.
+// It closes ready as soon as it sees readyMarker, or closes exited if the
+// stream ends (the process exited) before that happens.
+func watchLog(stdout io.Reader, logs *logCapture, ready, exited chan struct{}) {
+	foundReady := false
+
+	scanner := bufio.NewScanner(io.TeeReader(stdout, logs))
+	for scanner.Scan() {
+		if !foundReady && strings.Contains(scanner.Text(), readyMarker) {
+			foundReady = true
+			close(ready)
+		}
+	}
+
+	if !foundReady {
+		close(exited)
+	}
+}
+
 // Stop the database and remove storage files.
 func (s *Redis) Stop() error {
 	if s == nil {
 		return nil
 	}
 
+	if s.mini != nil {
+		s.mini.Close()
+		return nil
+	}
+
 	defer func() {
 		// Always try to remove it
 		os.RemoveAll(s.dir)
 	}()
 
-	err := s.cmd.Process.Signal(os.Interrupt)
+	pid := s.cmd.Process.Pid
+	err := killGroup(pid, syscall.SIGINT)
 	if err != nil {
+		// The signal never went out, so for all we know the process is
+		// still alive: leave it registered so the orphan reaper in
+		// cleanup.go still tracks it.
 		return err
 	}
 
-	err = s.cmd.Wait()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.cmd.Wait()
+	}()
+
+	grace := s.stopGrace
+	if grace <= 0 {
+		grace = defaultStopGrace
+	}
+
+	select {
+	case err = <-done:
+	case <-time.After(grace):
+		// It didn't shut down cleanly in time, so stop being polite.
+		// We forced this exit ourselves, so its status isn't a failure.
+		killGroup(pid, syscall.SIGKILL)
+		<-done
+		err = nil
+	}
+
+	// cmd.Wait returning means the process is actually gone, so it's safe
+	// to stop tracking it here.
+	unregisterActive(s)
+
 	if err != nil {
 		return err
 	}
@@ -162,20 +368,6 @@ func (s *Redis) Stop() error {
 	return nil
 }
 
-// Hang the server, good for testing blocked connections
-func (s *Redis) Freeze() {
-	if s.cmd != nil {
-		s.cmd.Process.Signal(syscall.SIGSTOP)
-	}
-}
-
-// Resume the server
-func (s *Redis) Continue() {
-	if s.cmd != nil {
-		s.cmd.Process.Signal(syscall.SIGCONT)
-	}
-}
-
 // Needed because Ubuntu doesn't put initdb in $PATH
 func findBinPath() (string, error) {
 	// In $PATH (e.g. Fedora) great!
@@ -184,23 +376,7 @@ func findBinPath() (string, error) {
 		return path.Dir(p), nil
 	}
 
-	return "", fmt.Errorf("Did not find Redis executables installed")
-}
-
-func retry(fn func() error, attempts int, interval time.Duration) error {
-	for {
-		err := fn()
-		if err == nil {
-			return nil
-		}
-
-		attempts -= 1
-		if attempts <= 0 {
-			return err
-		}
-
-		time.Sleep(interval)
-	}
+	return "", errNoBinary
 }
 
 func abort(msg string, cmd *exec.Cmd, stderr, stdout io.ReadCloser, err error) error {
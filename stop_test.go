@@ -0,0 +1,32 @@
+package redistest_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rubenv/redistest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStopEscalatesToSIGKILL(t *testing.T) {
+	requireRedisServer(t)
+
+	assert := assert.New(t)
+
+	red, err := redistest.Start(redistest.StartOptions{
+		StopGrace: 200 * time.Millisecond,
+	})
+	assert.NoError(err)
+	assert.NotNil(red)
+
+	// SIGSTOP it so it can't possibly honor the SIGINT Stop() sends,
+	// forcing the SIGKILL escalation path.
+	red.Freeze()
+
+	start := time.Now()
+	err = red.Stop()
+	elapsed := time.Since(start)
+
+	assert.NoError(err)
+	assert.Less(elapsed, 2*time.Second, "Stop() should escalate to SIGKILL instead of hanging")
+}
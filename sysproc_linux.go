@@ -0,0 +1,22 @@
+//go:build linux
+
+package redistest
+
+import "syscall"
+
+// procAttr puts the child in its own process group, so the whole group can
+// be signalled at once, and asks the kernel to kill it the moment we die
+// via PR_SET_PDEATHSIG. That way a SIGKILLed or OOM-killed test binary
+// can't leave a redis-server orphan behind, even though it has no chance
+// to run its own cleanup code.
+func procAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+}
+
+// killGroup signals every process in pid's group.
+func killGroup(pid int, sig syscall.Signal) error {
+	return syscall.Kill(-pid, sig)
+}
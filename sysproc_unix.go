@@ -0,0 +1,20 @@
+//go:build unix && !linux
+
+package redistest
+
+import "syscall"
+
+// procAttr puts the child in its own process group, so the whole group can
+// be signalled at once. There's no PR_SET_PDEATHSIG equivalent outside
+// Linux, so this relies on Stop()'s own cleanup and the signal handler
+// registered in cleanup.go.
+func procAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+}
+
+// killGroup signals every process in pid's group.
+func killGroup(pid int, sig syscall.Signal) error {
+	return syscall.Kill(-pid, sig)
+}
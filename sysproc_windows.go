@@ -0,0 +1,26 @@
+//go:build windows
+
+package redistest
+
+import (
+	"os"
+	"syscall"
+)
+
+// Windows has no process groups or PR_SET_PDEATHSIG equivalent, so there's
+// nothing extra to attach here; Stop()'s own signal/kill handling is all
+// we get.
+func procAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// killGroup just kills the process itself, whatever sig is: os.Process
+// on Windows can only deliver Kill (via TerminateProcess), and there's no
+// group semantics to replicate.
+func killGroup(pid int, sig syscall.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}